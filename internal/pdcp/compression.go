@@ -0,0 +1,65 @@
+package pdcp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	errorutil "github.com/projectdiscovery/utils/errors"
+)
+
+// compressionEnvVar opts a scan into zstd compression for pdcp uploads
+// instead of the gzip default. Set to any non-empty value to enable it.
+const compressionEnvVar = "NUCLEI_PDCP_ZSTD"
+
+// encodingIdentity, encodingGzip and encodingZstd are the Content-Encoding
+// values used for pdcp chunk uploads.
+const (
+	encodingIdentity = "identity"
+	encodingGzip     = "gzip"
+	encodingZstd     = "zstd"
+)
+
+// preferredEncoding returns the compression scheme a fresh upload should
+// attempt, before any per-server negotiation has taken place.
+func preferredEncoding() string {
+	if os.Getenv(compressionEnvVar) != "" {
+		return encodingZstd
+	}
+	return encodingGzip
+}
+
+// compress encodes data using the given Content-Encoding scheme. Callers
+// pass encodingIdentity to opt out, e.g. once a server has been observed to
+// not support compression.
+func compress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case encodingGzip:
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(data); err != nil {
+			return nil, errorutil.NewWithErr(err).Msgf("could not gzip upload payload")
+		}
+		if err := gw.Close(); err != nil {
+			return nil, errorutil.NewWithErr(err).Msgf("could not close gzip writer")
+		}
+		return buf.Bytes(), nil
+	case encodingZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, errorutil.NewWithErr(err).Msgf("could not create zstd encoder")
+		}
+		defer enc.Close()
+		return enc.EncodeAll(data, nil), nil
+	default:
+		return data, nil
+	}
+}
+
+// indicatesNoCompressionSupport reports whether an upload response signals
+// that the server does not accept compressed request bodies, so the writer
+// can fall back to identity encoding for the rest of the scan.
+func indicatesNoCompressionSupport(statusCode int, header string) bool {
+	return statusCode == 415 || header != ""
+}
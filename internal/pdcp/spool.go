@@ -0,0 +1,146 @@
+package pdcp
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	errorutil "github.com/projectdiscovery/utils/errors"
+	folderutil "github.com/projectdiscovery/utils/folder"
+)
+
+const (
+	spoolFileExt = ".jsonl"
+	spoolDoneExt = ".done"
+
+	backoffBase = 2 * time.Second
+	backoffMax  = 60 * time.Second
+)
+
+// spool is an on-disk queue of result chunks pending upload for a single
+// scan. Chunks are written to disk before an upload attempt is made, so a
+// crash or a flaky network does not lose results that were already read
+// from the output pipe. Chunks are marked done only after a successful
+// upload, and any chunk left without a matching done-marker is replayed
+// the next time a spool for the same scan-id is opened.
+type spool struct {
+	dir     string
+	counter int
+}
+
+// newSpool creates (or reopens) the on-disk spool directory for scanID.
+func newSpool(scanID string) (*spool, error) {
+	base, err := folderutil.AppConfigDirOrDefault("", "nuclei")
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not get config dir for spool")
+	}
+	dir := filepath.Join(base, "pdcp-spool", scanID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create spool dir")
+	}
+	return &spool{dir: dir}, nil
+}
+
+// write persists a chunk to disk before it is uploaded and returns the path
+// it was written to so the caller can mark it done on success.
+func (s *spool) write(data []byte) (string, error) {
+	s.counter++
+	path := filepath.Join(s.dir, fmt.Sprintf("%08d%s", s.counter, spoolFileExt))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", errorutil.NewWithErr(err).Msgf("could not write spool chunk")
+	}
+	return path, nil
+}
+
+// rename moves the spool directory to the path for newID, used once a
+// locally generated placeholder scan-id is replaced by the scan-id the
+// server assigned on the first successful upload.
+func (s *spool) rename(newID string) error {
+	newDir := filepath.Join(filepath.Dir(s.dir), newID)
+	if newDir == s.dir {
+		return nil
+	}
+	if err := os.Rename(s.dir, newDir); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not rename spool dir")
+	}
+	s.dir = newDir
+	return nil
+}
+
+// markDone records that the chunk at path was uploaded successfully.
+func (s *spool) markDone(path string) error {
+	if err := os.WriteFile(path+spoolDoneExt, nil, 0644); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not mark spool chunk done")
+	}
+	return nil
+}
+
+// remove deletes the spool directory and everything left in it. It is only
+// safe to call once the caller has confirmed no chunks are left pending.
+func (s *spool) remove() error {
+	if err := os.RemoveAll(s.dir); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not remove spool dir")
+	}
+	return nil
+}
+
+// pending returns the paths of all chunks that were written but never
+// marked done, in the order they were originally spooled.
+func (s *spool) pending() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not read spool dir")
+	}
+	done := make(map[string]struct{})
+	var chunks []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, spoolDoneExt) {
+			done[strings.TrimSuffix(name, spoolDoneExt)] = struct{}{}
+			continue
+		}
+		if strings.HasSuffix(name, spoolFileExt) {
+			chunks = append(chunks, name)
+		}
+	}
+	sort.Strings(chunks)
+
+	var pending []string
+	maxSeen := 0
+	for _, name := range chunks {
+		if _, ok := done[name]; !ok {
+			pending = append(pending, filepath.Join(s.dir, name))
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(name, spoolFileExt)); err == nil && n > maxSeen {
+			maxSeen = n
+		}
+	}
+	// resume numbering after the highest chunk already on disk
+	s.counter = maxSeen
+	return pending, nil
+}
+
+// backoffWithJitter returns a retry delay for the given attempt number
+// (starting at 0), doubling the base delay up to backoffMax and adding up
+// to 20% jitter to avoid synchronized retries across many running scans.
+func backoffWithJitter(attempt int) time.Duration {
+	delay := backoffBase << attempt
+	if delay <= 0 || delay > backoffMax {
+		delay = backoffMax
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 5))
+	return delay + jitter
+}
+
+// isRetryableStatusCode reports whether an HTTP status code represents a
+// transient failure (server error or rate limiting) worth retrying, as
+// opposed to a terminal failure (bad auth, bad request) that will never
+// succeed on retry.
+func isRetryableStatusCode(code int) bool {
+	return code >= 500 || code == 429
+}
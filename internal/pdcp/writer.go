@@ -5,13 +5,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/retryablehttp-go"
 	pdcpauth "github.com/projectdiscovery/utils/auth/pdcp"
@@ -26,9 +29,34 @@ const (
 	uploadEndpoint = "/v1/scans/import"
 	appendEndpoint = "/v1/scans/%s/import"
 	flushTimer     = time.Duration(1) * time.Minute
-	MaxChunkSize   = 1024 * 1024 * 4 // 4 MB
+
+	// MaxChunkSize is the logical, pre-compression size at which a chunk is
+	// flushed for upload. Compression usually shrinks this well below the
+	// wire cap enforced by maxCompressedChunkSize.
+	MaxChunkSize = 1024 * 1024 * 16 // 16 MB
+
+	// maxCompressedChunkSize is the hard cap on the post-compression,
+	// on-the-wire request body size. If a logical chunk still compresses
+	// above this (e.g. incompressible data, or compression disabled after
+	// negotiation), it is halved on line boundaries and re-attempted; a single
+	// line that is still above the cap is sent as-is.
+	maxCompressedChunkSize = 1024 * 1024 * 4 // 4 MB
+
+	// defaultSpoolDrainDeadline is how long Close waits for the spool to
+	// finish uploading already-written chunks before giving up. Overridable
+	// via NUCLEI_PDCP_SPOOL_DRAIN_SECONDS for CI/embedding use cases.
+	defaultSpoolDrainDeadline = 30 * time.Second
 )
 
+func spoolDrainDeadline() time.Duration {
+	if raw := os.Getenv("NUCLEI_PDCP_SPOOL_DRAIN_SECONDS"); raw != "" {
+		if secs, err := time.ParseDuration(raw + "s"); err == nil {
+			return secs
+		}
+	}
+	return defaultSpoolDrainDeadline
+}
+
 var _ output.Writer = &UploadWriter{}
 
 // UploadWriter is a writer that uploads its output to pdcp
@@ -42,16 +70,60 @@ type UploadWriter struct {
 	done      chan struct{}
 	scanID    string
 	counter   atomic.Int32
+
+	spool         *spool
+	spoolID       string
+	terminalErr   error
+	noCompression atomic.Bool
+}
+
+// encoding returns the Content-Encoding to use for the next upload attempt,
+// falling back to identity once a server has been observed to reject
+// compressed bodies.
+func (u *UploadWriter) encoding() string {
+	if u.noCompression.Load() {
+		return encodingIdentity
+	}
+	return preferredEncoding()
 }
 
-// NewUploadWriter creates a new upload writer
-func NewUploadWriter(ctx context.Context, creds *pdcpauth.PDCPCredentials) (*UploadWriter, error) {
+// splitForWireCap splits data on line boundaries until each part compresses
+// under maxCompressedChunkSize. A part that is a single line is not split
+// further and is returned as-is, even if it still compresses above the cap.
+func (u *UploadWriter) splitForWireCap(data []byte) [][]byte {
+	encoding := u.encoding()
+	if compressed, err := compress(encoding, data); err == nil && len(compressed) <= maxCompressedChunkSize {
+		return [][]byte{data}
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	// a trailing newline leaves an empty last element, which is not a line
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		// nothing left to split on, send as-is rather than loop forever
+		return [][]byte{data}
+	}
+	mid := len(lines) / 2
+	first := bytes.Join(lines[:mid], nil)
+	second := bytes.Join(lines[mid:], nil)
+	return append(u.splitForWireCap(first), u.splitForWireCap(second)...)
+}
+
+// NewUploadWriter creates a new upload writer. If resumeScanID is non-empty
+// (e.g. the caller is resuming a previously interrupted scan with a known
+// scan-id), the writer spools directly under that scan-id so any chunks
+// left over from the previous run are discovered and replayed; otherwise
+// a local placeholder id is used until the server assigns a real scan-id
+// on the first successful upload.
+func NewUploadWriter(ctx context.Context, creds *pdcpauth.PDCPCredentials, resumeScanID string) (*UploadWriter, error) {
 	if creds == nil {
 		return nil, fmt.Errorf("no credentials provided")
 	}
 	u := &UploadWriter{
-		creds: creds,
-		done:  make(chan struct{}, 1),
+		creds:  creds,
+		done:   make(chan struct{}, 1),
+		scanID: resumeScanID,
 	}
 	var err error
 	reader, writer := io.Pipe()
@@ -77,6 +149,20 @@ func NewUploadWriter(ctx context.Context, creds *pdcpauth.PDCPCredentials) (*Upl
 	opts.Timeout = time.Duration(3) * time.Minute
 	u.client = retryablehttp.NewClient(opts)
 
+	// the spool is keyed by scan-id, but the scan-id is normally assigned by
+	// the server on the first successful upload, so spool under a local
+	// placeholder id until then. resumeScanID lets a caller that already
+	// knows the scan-id spool directly under it so any chunks left over
+	// from that run are replayed below.
+	u.spoolID = u.scanID
+	if u.spoolID == "" {
+		u.spoolID = "pending-" + uuid.NewString()
+	}
+	u.spool, err = newSpool(u.spoolID)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create pdcp spool")
+	}
+
 	// create context
 	ctx, u.cancel = context.WithCancel(ctx)
 	// start auto commit
@@ -85,11 +171,6 @@ func NewUploadWriter(ctx context.Context, creds *pdcpauth.PDCPCredentials) (*Upl
 	return u, nil
 }
 
-// SetScanID sets the scan id for the upload writer
-func (u *UploadWriter) SetScanID(id string) {
-	u.scanID = id
-}
-
 func (u *UploadWriter) autoCommit(ctx context.Context, r *io.PipeReader) {
 	reader := bufio.NewReader(r)
 	ch := make(chan string, 4)
@@ -119,6 +200,11 @@ func (u *UploadWriter) autoCommit(ctx context.Context, r *io.PipeReader) {
 			gologger.Info().Msgf("%v Scan results uploaded to cloud, you can view scan results at %v", u.counter.Load(), getScanDashBoardURL(u.scanID))
 		}
 	}()
+
+	// replay any chunks left over from a previous, interrupted run of this
+	// scan before resuming live upload
+	u.replaySpool()
+
 	// temporary buffer to store the results
 	buff := &bytes.Buffer{}
 	ticker := time.NewTicker(flushTimer)
@@ -128,74 +214,175 @@ func (u *UploadWriter) autoCommit(ctx context.Context, r *io.PipeReader) {
 		case <-ctx.Done():
 			// flush before exit
 			if buff.Len() > 0 {
-				if err := u.uploadChunk(buff); err != nil {
-					gologger.Error().Msgf("Failed to upload scan results on cloud: %v", err)
-				}
+				u.spoolAndUpload(buff.Bytes())
+				buff.Reset()
 			}
 			return
 		case <-ticker.C:
 			// flush the buffer
 			if buff.Len() > 0 {
-				if err := u.uploadChunk(buff); err != nil {
-					gologger.Error().Msgf("Failed to upload scan results on cloud: %v", err)
-				}
+				u.spoolAndUpload(buff.Bytes())
+				buff.Reset()
 			}
 		case line, ok := <-ch:
 			if !ok {
 				if buff.Len() > 0 {
-					if err := u.uploadChunk(buff); err != nil {
-						gologger.Error().Msgf("Failed to upload scan results on cloud: %v", err)
-					}
+					u.spoolAndUpload(buff.Bytes())
+					buff.Reset()
 				}
 				return
 			}
 			if buff.Len()+len(line) > MaxChunkSize {
 				// flush existing buffer
-				if err := u.uploadChunk(buff); err != nil {
-					gologger.Error().Msgf("Failed to upload scan results on cloud: %v", err)
-				}
-			} else {
-				buff.WriteString(line)
+				u.spoolAndUpload(buff.Bytes())
+				buff.Reset()
 			}
+			buff.WriteString(line)
 		}
 	}
 }
 
-// uploadChunk uploads a chunk of data to the server
-func (u *UploadWriter) uploadChunk(buff *bytes.Buffer) error {
-	if err := u.upload(buff.Bytes()); err != nil {
-		return errorutil.NewWithErr(err).Msgf("could not upload chunk")
+// replaySpool uploads any chunks left on disk from a previous run that were
+// never marked done, in their original order, before live upload resumes.
+func (u *UploadWriter) replaySpool() {
+	pending, err := u.spool.pending()
+	if err != nil {
+		gologger.Warning().Msgf("Could not read pdcp spool, skipping replay: %v", err)
+		return
+	}
+	for _, path := range pending {
+		if u.terminalErr != nil {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			gologger.Warning().Msgf("Could not read spooled chunk %v: %v", path, err)
+			continue
+		}
+		if err := u.uploadWithRetry(data); err != nil {
+			gologger.Error().Msgf("Failed to replay spooled scan results: %v", err)
+			return
+		}
+		if err := u.spool.markDone(path); err != nil {
+			gologger.Warning().Msgf("Could not mark spooled chunk done: %v", err)
+		}
+	}
+}
+
+// spoolAndUpload persists data to disk before attempting the upload, so a
+// failed or interrupted attempt can be replayed on the next run instead of
+// silently dropping the chunk. Data is split further if it would still
+// exceed the post-compression wire cap.
+func (u *UploadWriter) spoolAndUpload(data []byte) {
+	for _, part := range u.splitForWireCap(data) {
+		u.spoolAndUploadChunk(part)
+	}
+}
+
+func (u *UploadWriter) spoolAndUploadChunk(data []byte) {
+	chunk := make([]byte, len(data))
+	copy(chunk, data)
+
+	path, err := u.spool.write(chunk)
+	if err != nil {
+		gologger.Error().Msgf("Could not spool scan results chunk, upload may be lost on failure: %v", err)
+		if uploadErr := u.uploadWithRetry(chunk); uploadErr != nil {
+			gologger.Error().Msgf("Failed to upload scan results on cloud: %v", uploadErr)
+		}
+		return
+	}
+	if err := u.uploadWithRetry(chunk); err != nil {
+		gologger.Error().Msgf("Failed to upload scan results on cloud, will retry from spool: %v", err)
+		return
+	}
+	if err := u.spool.markDone(path); err != nil {
+		gologger.Warning().Msgf("Could not mark spooled chunk done: %v", err)
 	}
-	// if successful, reset the buffer
-	buff.Reset()
-	// log in verbose mode
-	gologger.Warning().Msgf("Uploaded results chunk, you can view scan results at %v", getScanDashBoardURL(u.scanID))
-	return nil
 }
 
+// uploadWithRetry uploads data, retrying with exponential backoff and
+// jitter on transient (network, 5xx, 429) errors. A terminal error (4xx
+// auth/validation failures) aborts the retry loop immediately, records
+// itself on the writer so no further attempts are made, and is returned so
+// the caller can surface it to the user instead of retrying silently
+// forever.
+func (u *UploadWriter) uploadWithRetry(data []byte) error {
+	if u.terminalErr != nil {
+		return u.terminalErr
+	}
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		err := u.upload(data)
+		if err == nil {
+			gologger.Warning().Msgf("Uploaded results chunk, you can view scan results at %v", getScanDashBoardURL(u.scanID))
+			return nil
+		}
+		var uerr *uploadError
+		if errors.As(err, &uerr) && !uerr.retryable {
+			u.terminalErr = err
+			gologger.Error().Msgf("Scan results upload stopped, tracker returned a non-retryable error: %v", err)
+			return err
+		}
+		lastErr = err
+		if attempt >= maxUploadAttempts-1 {
+			return lastErr
+		}
+		time.Sleep(backoffWithJitter(attempt))
+	}
+}
+
+// uploadError distinguishes transient (network, 5xx, 429) failures, which
+// are worth retrying, from terminal (4xx auth/validation) failures, which
+// are not.
+type uploadError struct {
+	retryable bool
+	err       error
+}
+
+func (e *uploadError) Error() string { return e.err.Error() }
+func (e *uploadError) Unwrap() error { return e.err }
+
+// maxUploadAttempts bounds the retry loop for a single chunk so a
+// persistently unreachable server does not spin forever; the chunk stays
+// on disk un-marked-done and is replayed on the next run regardless.
+const maxUploadAttempts = 5
+
 func (u *UploadWriter) upload(data []byte) error {
-	req, err := u.getRequest(data)
+	encoding := u.encoding()
+	req, err := u.getRequest(data, encoding)
 	if err != nil {
-		return errorutil.NewWithErr(err).Msgf("could not create upload request")
+		return &uploadError{retryable: false, err: errorutil.NewWithErr(err).Msgf("could not create upload request")}
 	}
 	resp, err := u.client.Do(req)
 	if err != nil {
-		return errorutil.NewWithErr(err).Msgf("could not upload results")
+		return &uploadError{retryable: true, err: errorutil.NewWithErr(err).Msgf("could not upload results")}
 	}
 	defer resp.Body.Close()
 	bin, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return errorutil.NewWithErr(err).Msgf("could not get id from response")
+		return &uploadError{retryable: true, err: errorutil.NewWithErr(err).Msgf("could not get id from response")}
+	}
+	if encoding != encodingIdentity && !u.noCompression.Load() &&
+		indicatesNoCompressionSupport(resp.StatusCode, resp.Header.Get("X-PDCP-No-Compression")) {
+		// cache the decision for the rest of this scan and retry uncompressed
+		u.noCompression.Store(true)
+		return &uploadError{retryable: true, err: fmt.Errorf("server does not support compressed uploads, retrying as identity")}
 	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("could not upload results got status code %v on %v", resp.StatusCode, resp.Request.URL.String())
+		uerr := fmt.Errorf("could not upload results got status code %v on %v", resp.StatusCode, resp.Request.URL.String())
+		return &uploadError{retryable: isRetryableStatusCode(resp.StatusCode), err: uerr}
 	}
 	var uploadResp uploadResponse
 	if err := json.Unmarshal(bin, &uploadResp); err != nil {
-		return errorutil.NewWithErr(err).Msgf("could not unmarshal response got %v", string(bin))
+		return &uploadError{retryable: false, err: errorutil.NewWithErr(err).Msgf("could not unmarshal response got %v", string(bin))}
 	}
 	if uploadResp.ID != "" && u.scanID == "" {
 		u.scanID = uploadResp.ID
+		if err := u.spool.rename(u.scanID); err != nil {
+			gologger.Warning().Msgf("Could not rename pdcp spool to scan-id: %v", err)
+		} else {
+			u.spoolID = u.scanID
+		}
 	}
 	return nil
 }
@@ -203,7 +390,8 @@ func (u *UploadWriter) upload(data []byte) error {
 // getRequest returns a new request for upload
 // if scanID is not provided create new scan by uploading the data
 // if scanID is provided append the data to existing scan
-func (u *UploadWriter) getRequest(bin []byte) (*retryablehttp.Request, error) {
+// the payload is compressed using encoding unless encoding is identity
+func (u *UploadWriter) getRequest(bin []byte, encoding string) (*retryablehttp.Request, error) {
 	var method, url string
 
 	if u.scanID == "" {
@@ -215,7 +403,17 @@ func (u *UploadWriter) getRequest(bin []byte) (*retryablehttp.Request, error) {
 		method = http.MethodPatch
 		url = u.uploadURL.String()
 	}
-	req, err := retryablehttp.NewRequest(method, url, bytes.NewReader(bin))
+
+	payload := bin
+	if encoding != encodingIdentity {
+		compressed, err := compress(encoding, bin)
+		if err != nil {
+			return nil, errorutil.NewWithErr(err).Msgf("could not compress upload payload")
+		}
+		payload = compressed
+	}
+
+	req, err := retryablehttp.NewRequest(method, url, bytes.NewReader(payload))
 	if err != nil {
 		return nil, errorutil.NewWithErr(err).Msgf("could not create cloud upload request")
 	}
@@ -224,12 +422,37 @@ func (u *UploadWriter) getRequest(bin []byte) (*retryablehttp.Request, error) {
 	req.Header.Set(pdcpauth.ApiKeyHeaderName, u.creds.APIKey)
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.Header.Set("Accept", "application/json")
+	if encoding != encodingIdentity {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	req.ContentLength = int64(len(payload))
 	return req, nil
 }
 
-// Close closes the upload writer
+// Close closes the upload writer. It blocks until the spool has finished
+// draining (i.e. the final in-flight chunk has been uploaded or spooled)
+// or until spoolDrainDeadline elapses, whichever comes first, so a slow or
+// unreachable server cannot hang process shutdown indefinitely. Any chunk
+// that didn't finish draining remains on disk and is replayed the next
+// time a writer is opened for the same scan-id. Once the spool has fully
+// drained, its on-disk directory is removed; if anything is still pending
+// (timeout, or a terminal error aborted the upload), it is left in place
+// for the next run to find and replay.
 func (u *UploadWriter) Close() {
 	u.cancel()
-	<-u.done
+	drained := true
+	select {
+	case <-u.done:
+	case <-time.After(spoolDrainDeadline()):
+		gologger.Warning().Msgf("Timed out waiting for pdcp spool to drain, remaining results will upload on next run")
+		drained = false
+	}
+	if drained && u.terminalErr == nil {
+		if pending, err := u.spool.pending(); err == nil && len(pending) == 0 {
+			if err := u.spool.remove(); err != nil {
+				gologger.Warning().Msgf("Could not remove pdcp spool dir: %v", err)
+			}
+		}
+	}
 	u.StandardWriter.Close()
 }
@@ -0,0 +1,7 @@
+package compiler
+
+import "github.com/scottdharvey/nuclei/v3/pkg/protocols/common/protocolinit"
+
+func init() {
+	protocolinit.RegisterProtocol("js-compiler", Init, nil)
+}
@@ -18,7 +18,7 @@ func WriteResult(data *output.InternalWrappedEvent, output output.Writer, progre
 	var matched bool
 	for _, result := range data.Results {
 		if issuesClient != nil {
-			if err := issuesClient.CreateIssue(result); err != nil {
+			if err := issuesClient.FindOrUpdateIssue(result); err != nil {
 				gologger.Warning().Msgf("Could not create issue on tracker: %s", err)
 			}
 		}
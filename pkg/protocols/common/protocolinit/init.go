@@ -1,43 +1,119 @@
+// Package protocolinit initializes the client pools shared by nuclei's
+// protocol implementations (DNS, HTTP, network, ...) before a scan starts.
 package protocolinit
 
 import (
-	"github.com/scottdharvey/nuclei/v3/pkg/js/compiler"
+	"fmt"
+	"strings"
+	"sync"
+
 	"github.com/scottdharvey/nuclei/v3/pkg/protocols/common/protocolstate"
-	"github.com/scottdharvey/nuclei/v3/pkg/protocols/dns/dnsclientpool"
-	"github.com/scottdharvey/nuclei/v3/pkg/protocols/http/httpclientpool"
-	"github.com/scottdharvey/nuclei/v3/pkg/protocols/http/signerpool"
-	"github.com/scottdharvey/nuclei/v3/pkg/protocols/network/networkclientpool"
-	"github.com/scottdharvey/nuclei/v3/pkg/protocols/whois/rdapclientpool"
 	"github.com/scottdharvey/nuclei/v3/pkg/types"
 )
 
-// Init initializes the client pools for the protocols
-func Init(options *types.Options) error {
+// protocolEntry is a single registered protocol client pool.
+type protocolEntry struct {
+	name  string
+	init  func(*types.Options) error
+	close func()
+}
 
-	if err := protocolstate.Init(options); err != nil {
-		return err
-	}
-	if err := dnsclientpool.Init(options); err != nil {
-		return err
-	}
-	if err := httpclientpool.Init(options); err != nil {
-		return err
+var mu sync.Mutex
+
+// registry holds every protocol registered via RegisterProtocol, in
+// registration order. Init and Close iterate it deterministically so
+// behavior does not depend on map ordering. Guarded by mu.
+var registry []protocolEntry
+
+// RegisterProtocol registers a protocol client pool to be initialized by
+// Init and torn down by Close. init may be nil if the protocol has no setup
+// step, and close may be nil if it has nothing to tear down. Every built-in
+// protocol (dnsclientpool, httpclientpool, signerpool, networkclientpool,
+// rdapclientpool, the js compiler, ...) calls this from an init() function
+// in its own package; third-party embedders and out-of-tree protocols
+// (gRPC, AMQP, MQTT, Kafka probes, ...) do the same to plug in without
+// patching this file.
+func RegisterProtocol(name string, init func(*types.Options) error, close func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry = append(registry, protocolEntry{name: name, init: init, close: close})
+}
+
+// initConfig holds the options accepted by Init.
+type initConfig struct {
+	disabled map[string]struct{}
+}
+
+// Option configures Init's behavior.
+type Option func(*initConfig)
+
+// WithDisabledProtocols opts the given protocol names out of initialization,
+// e.g. to keep an airgapped run from initializing WHOIS/RDAP.
+func WithDisabledProtocols(names []string) Option {
+	return func(c *initConfig) {
+		for _, name := range names {
+			c.disabled[name] = struct{}{}
+		}
 	}
-	if err := signerpool.Init(options); err != nil {
-		return err
+}
+
+// Handle tracks the entries a single Init call successfully set up, so its
+// Close only tears down the protocol client pools that call actually started.
+// Every Handle's Close also closes the shared dialer, which all Init calls in
+// the process use.
+type Handle struct {
+	entries []protocolEntry
+}
+
+// Init initializes the client pools for the protocols and returns a Handle
+// scoped to this call. Registered protocols are initialized in registration
+// order; a protocol that fails to initialize is recorded by name and
+// initialization continues for the rest, with all failures aggregated into
+// the returned error.
+func Init(options *types.Options, opts ...Option) (*Handle, error) {
+	cfg := &initConfig{disabled: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(cfg)
 	}
-	if err := networkclientpool.Init(options); err != nil {
-		return err
+
+	if err := protocolstate.Init(options); err != nil {
+		return nil, err
 	}
-	if err := rdapclientpool.Init(options); err != nil {
-		return err
+
+	mu.Lock()
+	registered := append([]protocolEntry(nil), registry...)
+	mu.Unlock()
+
+	handle := &Handle{}
+	var failures []string
+	for _, entry := range registered {
+		if _, skip := cfg.disabled[entry.name]; skip {
+			continue
+		}
+		if entry.init != nil {
+			if err := entry.init(options); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s", entry.name, err))
+				continue
+			}
+		}
+		handle.entries = append(handle.entries, entry)
 	}
-	if err := compiler.Init(options); err != nil {
-		return err
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("could not initialize protocols: %s", strings.Join(failures, "; "))
 	}
-	return nil
+	return handle, nil
 }
 
-func Close() {
+// Close tears down the protocol client pools that the corresponding Init
+// call successfully set up, in addition to the shared dialer. The shared
+// dialer is closed unconditionally, so it should only be called once all
+// scans sharing this process are done.
+func (h *Handle) Close() {
 	protocolstate.Dialer.Close()
+
+	for _, entry := range h.entries {
+		if entry.close != nil {
+			entry.close()
+		}
+	}
 }
@@ -0,0 +1,7 @@
+package httpclientpool
+
+import "github.com/scottdharvey/nuclei/v3/pkg/protocols/common/protocolinit"
+
+func init() {
+	protocolinit.RegisterProtocol("http", Init, nil)
+}
@@ -0,0 +1,7 @@
+package networkclientpool
+
+import "github.com/scottdharvey/nuclei/v3/pkg/protocols/common/protocolinit"
+
+func init() {
+	protocolinit.RegisterProtocol("network", Init, nil)
+}
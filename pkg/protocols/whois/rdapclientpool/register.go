@@ -0,0 +1,7 @@
+package rdapclientpool
+
+import "github.com/scottdharvey/nuclei/v3/pkg/protocols/common/protocolinit"
+
+func init() {
+	protocolinit.RegisterProtocol("whois", Init, nil)
+}
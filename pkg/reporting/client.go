@@ -0,0 +1,100 @@
+package reporting
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/scottdharvey/nuclei/v3/pkg/output"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/dedupe"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/forgejo"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/gitea"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/github"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/gitlab"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/jira"
+)
+
+// Client is the interface implemented by every issue tracker nuclei can
+// report findings to (GitHub, GitLab, Gitea, Forgejo, Jira, ...).
+type Client interface {
+	// CreateIssue creates a new issue on the tracker for the given event,
+	// without checking whether a matching issue already exists.
+	CreateIssue(event *output.ResultEvent) error
+	// FindOrUpdateIssue reconciles event against the tracker: if an issue
+	// with a matching fingerprint already exists, it is updated in place
+	// (reopened if closed, a new occurrence comment appended, severity
+	// labels refreshed); otherwise a new issue is created. Callers should
+	// prefer this over CreateIssue to avoid flooding the tracker with
+	// duplicates on repeated scans.
+	FindOrUpdateIssue(event *output.ResultEvent) error
+}
+
+// New builds the tracker clients configured in options. Options.Dedupe, if
+// set, is applied as the default for any configured tracker that doesn't
+// specify its own Dedupe block, so a single top-level dedupe config covers
+// every tracker without having to be repeated under each of them. A tracker
+// that fails to construct is recorded by name and construction continues
+// for the rest, with all failures aggregated into the returned error.
+func New(options *Options) ([]Client, error) {
+	var clients []Client
+	var failures []string
+
+	if options.GitHub != nil {
+		options.GitHub.Dedupe = dedupeOrDefault(options.GitHub.Dedupe, options.Dedupe)
+		client, err := github.New(options.GitHub)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("github: %s", err))
+		} else {
+			clients = append(clients, client)
+		}
+	}
+	if options.GitLab != nil {
+		options.GitLab.Dedupe = dedupeOrDefault(options.GitLab.Dedupe, options.Dedupe)
+		client, err := gitlab.New(options.GitLab)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("gitlab: %s", err))
+		} else {
+			clients = append(clients, client)
+		}
+	}
+	if options.Gitea != nil {
+		options.Gitea.Dedupe = dedupeOrDefault(options.Gitea.Dedupe, options.Dedupe)
+		client, err := gitea.New(options.Gitea)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("gitea: %s", err))
+		} else {
+			clients = append(clients, client)
+		}
+	}
+	if options.Forgejo != nil {
+		options.Forgejo.Dedupe = dedupeOrDefault(options.Forgejo.Dedupe, options.Dedupe)
+		client, err := forgejo.New(options.Forgejo)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("forgejo: %s", err))
+		} else {
+			clients = append(clients, client)
+		}
+	}
+	if options.Jira != nil {
+		options.Jira.Dedupe = dedupeOrDefault(options.Jira.Dedupe, options.Dedupe)
+		client, err := jira.New(options.Jira)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("jira: %s", err))
+		} else {
+			clients = append(clients, client)
+		}
+	}
+
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("could not initialize issue trackers: %s", strings.Join(failures, "; "))
+	}
+	return clients, nil
+}
+
+// dedupeOrDefault returns tracker, or fallback if tracker is unset, so a
+// tracker-level Dedupe block always takes precedence over the top-level one.
+func dedupeOrDefault(tracker, fallback *dedupe.Options) *dedupe.Options {
+	if tracker != nil {
+		return tracker
+	}
+	return fallback
+}
@@ -0,0 +1,278 @@
+// Package oci implements an exporter that packages a scan's findings as an
+// OCI artifact and pushes it to any distribution-spec compliant registry
+// (ghcr.io, Harbor, ECR, Zot, ...).
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/retryablehttp-go"
+	errorutil "github.com/projectdiscovery/utils/errors"
+	"github.com/scottdharvey/nuclei/v3/pkg/output"
+)
+
+// artifactType is the OCI artifactType for a nuclei scan artifact.
+const artifactType = "application/vnd.projectdiscovery.nuclei.scan.v1+json"
+
+const (
+	mediaTypeConfig   = "application/vnd.projectdiscovery.nuclei.scan.config.v1+json"
+	mediaTypeJSONL    = "application/vnd.projectdiscovery.nuclei.scan.jsonl.v1"
+	mediaTypeSarif    = "application/sarif+json"
+	mediaTypeMarkdown = "text/markdown"
+	mediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// Options contains the configuration options for the OCI exporter.
+type Options struct {
+	// RegistryURL is the base url of the OCI distribution-spec registry
+	RegistryURL string `yaml:"registry-url"`
+	// Repository is the name of the repository to push the artifact to
+	Repository string `yaml:"repository"`
+	// TagTemplate is a template for the artifact tag, supports the {{scan_id}}
+	// and {{date}} placeholders. Defaults to "{{date}}-{{scan_id}}".
+	TagTemplate string `yaml:"tag-template"`
+	// TemplatesVersion is the version of the nuclei-templates repository the
+	// scan ran against, recorded in the pushed config blob. This is the
+	// templates release version, not nuclei's own binary version.
+	TemplatesVersion string `yaml:"templates-version"`
+
+	// Username and Password are used for basic/bearer auth against the registry
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// DockerConfigPath points to a docker config.json with registry credentials
+	DockerConfigPath string `yaml:"docker-config-path"`
+
+	// IncludeSarif adds a SARIF layer to the pushed artifact
+	IncludeSarif bool `yaml:"include-sarif"`
+	// IncludeMarkdown adds a markdown summary layer to the pushed artifact
+	IncludeMarkdown bool `yaml:"include-markdown"`
+
+	// CosignKeyPath is the cosign key path handed to the signing step after
+	// the manifest is pushed; that step does not sign the manifest itself.
+	CosignKeyPath string `yaml:"cosign-key-path"`
+
+	HttpClient *retryablehttp.Client `yaml:"-"`
+}
+
+// Exporter pushes nuclei scan findings as an OCI artifact once the scan
+// completes. Unlike the per-event trackers, results are buffered in memory
+// and the artifact is built and pushed as a whole on Close, since an OCI
+// manifest describes a fixed, complete set of layers.
+type Exporter struct {
+	options *Options
+	client  *retryablehttp.Client
+	results []*output.ResultEvent
+	start   time.Time
+}
+
+// descriptor is an OCI content descriptor (media type, size, digest).
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// manifest is a minimal OCI image manifest for a nuclei scan artifact.
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	ArtifactType  string       `json:"artifactType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// configBlob is the OCI config blob describing the scan that produced the artifact.
+type configBlob struct {
+	TemplatesVersion string    `json:"templatesVersion,omitempty"`
+	TargetListHash   string    `json:"targetListHash,omitempty"`
+	StartedAt        time.Time `json:"startedAt"`
+	EndedAt          time.Time `json:"endedAt"`
+	FindingsCount    int       `json:"findingsCount"`
+}
+
+// New creates a new OCI exporter.
+func New(options *Options) (*Exporter, error) {
+	if options.RegistryURL == "" || options.Repository == "" {
+		return nil, fmt.Errorf("oci exporter requires a registry-url and repository")
+	}
+	client := options.HttpClient
+	if client == nil {
+		client = retryablehttp.NewClient(retryablehttp.DefaultOptionsSingle)
+	}
+	return &Exporter{options: options, client: client, start: time.Now()}, nil
+}
+
+// Export buffers a result to be included in the artifact pushed on Close.
+func (e *Exporter) Export(event *output.ResultEvent) error {
+	e.results = append(e.results, event)
+	return nil
+}
+
+// Close builds the OCI artifact for the buffered results and pushes it to
+// the configured registry.
+func (e *Exporter) Close() error {
+	if len(e.results) == 0 {
+		return nil
+	}
+
+	layers := []blob{{mediaType: mediaTypeJSONL, data: e.jsonlLayer()}}
+	if e.options.IncludeSarif {
+		layers = append(layers, blob{mediaType: mediaTypeSarif, data: e.sarifLayer()})
+	}
+	if e.options.IncludeMarkdown {
+		layers = append(layers, blob{mediaType: mediaTypeMarkdown, data: e.markdownLayer()})
+	}
+
+	cfg := configBlob{
+		TemplatesVersion: e.options.TemplatesVersion,
+		TargetListHash:   e.targetListHash(),
+		StartedAt:        e.start,
+		EndedAt:          time.Now(),
+		FindingsCount:    len(e.results),
+	}
+	configBin, err := json.Marshal(cfg)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not marshal oci config blob")
+	}
+
+	pusher, err := newPusher(e.options, e.client)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not initialize oci registry client")
+	}
+
+	configDesc, err := pusher.pushBlob(mediaTypeConfig, configBin)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not push oci config blob")
+	}
+
+	layerDescs := make([]descriptor, 0, len(layers))
+	for _, l := range layers {
+		desc, err := pusher.pushBlob(l.mediaType, l.data)
+		if err != nil {
+			return errorutil.NewWithErr(err).Msgf("could not push oci layer %s", l.mediaType)
+		}
+		layerDescs = append(layerDescs, desc)
+	}
+
+	man := manifest{
+		SchemaVersion: 2,
+		ArtifactType:  artifactType,
+		Config:        configDesc,
+		Layers:        layerDescs,
+	}
+	manBin, err := json.Marshal(man)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not marshal oci manifest")
+	}
+	tag := e.resolveTag()
+	if err := pusher.pushManifest(tag, manBin); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not push oci manifest")
+	}
+	if e.options.CosignKeyPath != "" {
+		if err := pusher.sign(tag, e.options.CosignKeyPath); err != nil {
+			return errorutil.NewWithErr(err).Msgf("could not sign oci manifest")
+		}
+	}
+	return nil
+}
+
+type blob struct {
+	mediaType string
+	data      []byte
+}
+
+func (e *Exporter) jsonlLayer() []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, result := range e.results {
+		_ = enc.Encode(result)
+	}
+	return buf.Bytes()
+}
+
+func (e *Exporter) sarifLayer() []byte {
+	type sarifResult struct {
+		RuleID  string `json:"ruleId"`
+		Message struct {
+			Text string `json:"text"`
+		} `json:"message"`
+	}
+	type sarifRun struct {
+		Tool struct {
+			Driver struct {
+				Name string `json:"name"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	doc := struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}{Version: "2.1.0", Schema: "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json"}
+
+	run := sarifRun{}
+	run.Tool.Driver.Name = "nuclei"
+	for _, result := range e.results {
+		sr := sarifResult{RuleID: result.TemplateID}
+		sr.Message.Text = result.Info.Name
+		run.Results = append(run.Results, sr)
+	}
+	doc.Runs = []sarifRun{run}
+	bin, _ := json.Marshal(doc)
+	return bin
+}
+
+func (e *Exporter) markdownLayer() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Nuclei Scan Results\n\n")
+	for _, result := range e.results {
+		fmt.Fprintf(&buf, "- **%s** (%s) matched at `%s`\n", result.TemplateID, result.Info.SeverityHolder.Severity.String(), result.Matched)
+	}
+	return buf.Bytes()
+}
+
+// targetListHash returns a stable hash of the distinct matched targets in
+// the buffered results, so two artifacts can be compared to see whether
+// they were produced by scanning the same set of targets.
+func (e *Exporter) targetListHash() string {
+	seen := make(map[string]struct{}, len(e.results))
+	for _, result := range e.results {
+		seen[result.Matched] = struct{}{}
+	}
+	targets := make([]string, 0, len(seen))
+	for target := range seen {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return sha256Digest([]byte(strings.Join(targets, "\n")))
+}
+
+func (e *Exporter) resolveTag() string {
+	tmpl := e.options.TagTemplate
+	if tmpl == "" {
+		tmpl = "{{date}}-{{scan_id}}"
+	}
+	replacer := strings.NewReplacer(
+		"{{date}}", e.start.UTC().Format("20060102150405"),
+		"{{scan_id}}", digestPrefix(e.jsonlLayer()),
+	)
+	return replacer.Replace(tmpl)
+}
+
+func digestPrefix(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// sha256Digest computes an OCI-style "sha256:<hex>" digest for data.
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
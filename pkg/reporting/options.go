@@ -6,9 +6,12 @@ import (
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/exporters/jsonexporter"
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/exporters/jsonl"
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/exporters/markdown"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/exporters/oci"
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/exporters/sarif"
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/exporters/splunk"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/dedupe"
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/filters"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/forgejo"
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/gitea"
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/github"
 	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/gitlab"
@@ -27,6 +30,8 @@ type Options struct {
 	GitLab *gitlab.Options `yaml:"gitlab"`
 	// Gitea contains configuration options for Gitea Issue Tracker
 	Gitea *gitea.Options `yaml:"gitea"`
+	// Forgejo contains configuration options for Forgejo Issue Tracker
+	Forgejo *forgejo.Options `yaml:"forgejo"`
 	// Jira contains configuration options for Jira Issue Tracker
 	Jira *jira.Options `yaml:"jira"`
 	// MarkdownExporter contains configuration options for Markdown Exporter Module
@@ -41,6 +46,11 @@ type Options struct {
 	JSONExporter *jsonexporter.Options `yaml:"json"`
 	// JSONLExporter contains configuration options for JSONL Exporter Module
 	JSONLExporter *jsonl.Options `yaml:"jsonl"`
+	// OCIExporter contains configuration options for the OCI Registry Exporter Module
+	OCIExporter *oci.Options `yaml:"oci"`
+
+	// Dedupe configures fingerprint based issue deduplication across trackers
+	Dedupe *dedupe.Options `yaml:"dedupe"`
 
 	HttpClient *retryablehttp.Client `yaml:"-"`
 	OmitRaw    bool                  `yaml:"-"`
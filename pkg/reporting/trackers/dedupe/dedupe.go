@@ -0,0 +1,93 @@
+// Package dedupe provides fingerprint based issue deduplication shared by
+// the tracker implementations under pkg/reporting/trackers, so a re-run of
+// a scan can update an existing tracker issue in place instead of creating
+// a duplicate.
+package dedupe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/scottdharvey/nuclei/v3/pkg/output"
+)
+
+// LabelPrefix is the label/custom-field name prefix trackers use to store
+// an issue's fingerprint when Options.StorageMode is "label".
+const LabelPrefix = "nuclei-fingerprint:"
+
+// Options configures fingerprint based deduplication for a single tracker.
+type Options struct {
+	// FingerprintFields is the list of extracted-value keys included in the
+	// fingerprint, in addition to the template-id and matched-at which are
+	// always included. When empty, all extracted values are included.
+	FingerprintFields []string `yaml:"fingerprint-fields"`
+	// StorageMode controls where the fingerprint is recorded on the
+	// tracker: "comment" stores it in a hidden HTML comment in the issue
+	// body, "label" stores it as a nuclei-fingerprint:<sha256> label or
+	// custom field. Defaults to "comment".
+	StorageMode string `yaml:"storage-mode"`
+	// MaxCommentsPerIssue caps occurrence comments: once an issue has this
+	// many comments of any kind, further occurrences are not recorded as
+	// comments. Zero means unlimited.
+	MaxCommentsPerIssue int `yaml:"max-comments-per-issue"`
+}
+
+// UsesLabel reports whether fingerprints should be stored as a label/custom
+// field rather than a hidden comment in the issue body.
+func (o *Options) UsesLabel() bool {
+	return o != nil && o.StorageMode == "label"
+}
+
+// Fingerprint derives a stable identifier for event from its template-id,
+// matched-at value, and a configurable subset of its extracted values, so
+// the same underlying finding hashes identically across repeated scans
+// regardless of match ordering. When fields is empty, every value in the
+// event's ExtractedResults is included.
+func Fingerprint(event *output.ResultEvent, fields []string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "template:%s\n", event.TemplateID)
+	fmt.Fprintf(h, "matched:%s\n", event.Matched)
+
+	values := extractedValues(event, fields)
+	sort.Strings(values)
+	for _, v := range values {
+		fmt.Fprintf(h, "extracted:%s\n", v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// extractedValues returns the extracted values to include in the
+// fingerprint. If fields is non-empty, only extractions whose key matches
+// an entry in fields are included (via event.Metadata); otherwise every
+// value in event.ExtractedResults is included.
+func extractedValues(event *output.ResultEvent, fields []string) []string {
+	if len(fields) == 0 {
+		return append([]string{}, event.ExtractedResults...)
+	}
+	allow := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		allow[f] = struct{}{}
+	}
+	var values []string
+	for key, value := range event.Metadata {
+		if _, ok := allow[key]; ok {
+			values = append(values, fmt.Sprintf("%v", value))
+		}
+	}
+	return values
+}
+
+// Label returns the label/custom-field name used to store fingerprint on a
+// tracker that uses StorageMode "label".
+func Label(fingerprint string) string {
+	return LabelPrefix + fingerprint
+}
+
+// Comment returns the hidden HTML comment used to store fingerprint in an
+// issue body on a tracker that uses StorageMode "comment". It renders
+// invisibly when the issue is shown as markdown.
+func Comment(fingerprint string) string {
+	return fmt.Sprintf("<!-- %s%s -->", LabelPrefix, fingerprint)
+}
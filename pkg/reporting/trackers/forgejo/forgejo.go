@@ -0,0 +1,560 @@
+// Package forgejo implements a reporting.Client for Forgejo issue trackers.
+//
+// Forgejo has diverged from Gitea in ways that matter here: it authenticates
+// PATs via the OAuth2-style "Authorization: Bearer <token>" scheme rather
+// than Gitea's legacy "token <token>" header, and its issues API take label
+// and milestone IDs rather than bare names, so a label or milestone has to
+// be looked up (and created if it doesn't exist yet) before it can be
+// attached to an issue. Those differences are enough that the Gitea tracker
+// can't be pointed at a Forgejo instance as-is, so a dedicated client lives
+// here.
+package forgejo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/retryablehttp-go"
+	errorutil "github.com/projectdiscovery/utils/errors"
+	"github.com/scottdharvey/nuclei/v3/pkg/output"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/format"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/dedupe"
+)
+
+// Options contains the configuration options for the Forgejo issue tracker.
+type Options struct {
+	// BaseURL is the base url of the forgejo instance
+	BaseURL string `yaml:"base-url"`
+	// Token is the personal access token for the forgejo instance, sent as
+	// an OAuth2-style bearer token
+	Token string `yaml:"token"`
+	// Owner is the owner of the repository
+	Owner string `yaml:"owner"`
+	// Repository is the name of the repository
+	Repository string `yaml:"repository"`
+	// IssueTemplate is the issue template for reporting
+	IssueTemplate string `yaml:"issue-template"`
+	// SeverityAsLabel sends the severity as the label of the created issue
+	SeverityAsLabel bool `yaml:"severity-as-label"`
+	// SeverityLabels is a mapping of severity to an allow-listed label name.
+	// When empty, the raw severity string is used as the label.
+	SeverityLabels map[string]string `yaml:"severity-labels"`
+	// MilestoneName, if set, attaches every created issue to the named
+	// milestone, creating it on the repository if it doesn't exist yet
+	MilestoneName string `yaml:"milestone-name"`
+	// Dedupe configures fingerprint based issue deduplication. When nil,
+	// FindOrUpdateIssue still deduplicates, fingerprinting all extracted
+	// values and storing the fingerprint as a hidden comment in the body.
+	Dedupe *dedupe.Options `yaml:"dedupe"`
+
+	HttpClient *retryablehttp.Client `yaml:"-"`
+}
+
+// Integration is a client for the Forgejo issue tracker.
+type Integration struct {
+	options *Options
+	client  *retryablehttp.Client
+	baseURL *url.URL
+}
+
+// New creates a new issue tracker integration for Forgejo.
+func New(options *Options) (*Integration, error) {
+	if options.BaseURL == "" {
+		return nil, fmt.Errorf("forgejo base-url cannot be empty")
+	}
+	parsed, err := url.Parse(options.BaseURL)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not parse forgejo base-url")
+	}
+	client := options.HttpClient
+	if client == nil {
+		client = retryablehttp.NewClient(retryablehttp.DefaultOptionsSingle)
+	}
+	return &Integration{options: options, client: client, baseURL: parsed}, nil
+}
+
+// issuePayload is the request body for creating or updating a Forgejo
+// issue. Unlike Gitea, Forgejo rejects label/milestone names here and wants
+// their numeric IDs instead.
+type issuePayload struct {
+	Title     string  `json:"title,omitempty"`
+	Body      string  `json:"body,omitempty"`
+	Labels    []int64 `json:"labels,omitempty"`
+	Milestone int64   `json:"milestone,omitempty"`
+	State     string  `json:"state,omitempty"`
+}
+
+// commentPayload is the request body for adding a comment to an issue.
+type commentPayload struct {
+	Body string `json:"body"`
+}
+
+// issueResponse is the subset of the Forgejo issue response we care about.
+type issueResponse struct {
+	Index   int64          `json:"number"`
+	State   string         `json:"state"`
+	Body    string         `json:"body"`
+	Labels  []forgejoLabel `json:"labels,omitempty"`
+	HTMLURL string         `json:"html_url"`
+}
+
+// forgejoLabel is a repository label as returned by the Forgejo API.
+type forgejoLabel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// forgejoMilestone is a repository milestone as returned by the Forgejo API.
+type forgejoMilestone struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// CreateIssue creates a new issue on the configured Forgejo repository
+// unconditionally, without checking for an existing match. Most callers
+// should prefer FindOrUpdateIssue to avoid duplicate issues on repeated
+// scans.
+func (i *Integration) CreateIssue(event *output.ResultEvent) error {
+	_, err := i.createIssue(event, i.fingerprint(event))
+	return err
+}
+
+// FindOrUpdateIssue reconciles event against the tracker: if an issue
+// already exists for this finding's fingerprint, it is reopened if closed,
+// has a new occurrence comment appended, and has its severity labels
+// refreshed; otherwise a new issue is created with the fingerprint recorded
+// for future runs.
+func (i *Integration) FindOrUpdateIssue(event *output.ResultEvent) error {
+	fp := i.fingerprint(event)
+	existing, err := i.findByFingerprint(fp)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not search for existing forgejo issue")
+	}
+	if existing == nil {
+		_, err := i.createIssue(event, fp)
+		return err
+	}
+	return i.updateIssue(existing, event)
+}
+
+func (i *Integration) fingerprint(event *output.ResultEvent) string {
+	var fields []string
+	if i.options.Dedupe != nil {
+		fields = i.options.Dedupe.FingerprintFields
+	}
+	return dedupe.Fingerprint(event, fields)
+}
+
+// createIssue creates a new issue on the repository, embedding fingerprint
+// either as a hidden HTML comment in the body or as a label, depending on
+// the configured storage mode.
+func (i *Integration) createIssue(event *output.ResultEvent, fingerprint string) (*issueResponse, error) {
+	body := format.MarkdownDescription(event)
+	labelNames := i.labelsForSeverity(event)
+	if i.options.Dedupe.UsesLabel() {
+		labelNames = append(labelNames, dedupe.Label(fingerprint))
+	} else {
+		body = body + "\n\n" + dedupe.Comment(fingerprint)
+	}
+	labelIDs, err := i.resolveLabelIDs(labelNames)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not resolve forgejo label ids")
+	}
+
+	payload := issuePayload{Title: format.Summary(event), Body: body, Labels: labelIDs}
+	if i.options.MilestoneName != "" {
+		milestoneID, err := i.resolveMilestoneID(i.options.MilestoneName)
+		if err != nil {
+			return nil, errorutil.NewWithErr(err).Msgf("could not resolve forgejo milestone")
+		}
+		payload.Milestone = milestoneID
+	}
+
+	req, err := i.newRequest(http.MethodPost, i.issuesURL(), payload)
+	if err != nil {
+		return nil, err
+	}
+	issue, err := i.do(req, http.StatusCreated)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create forgejo issue")
+	}
+	return issue, nil
+}
+
+// updateIssue reopens a closed issue, appends an occurrence comment, and
+// refreshes severity labels on an existing issue that matched event's
+// fingerprint.
+func (i *Integration) updateIssue(existing *issueResponse, event *output.ResultEvent) error {
+	labelNames := i.labelsForSeverity(event)
+	if i.options.Dedupe.UsesLabel() {
+		// the PATCH below replaces the issue's full label set, so the
+		// fingerprint label has to be re-sent on every update or it gets
+		// wiped, breaking findByFingerprint on the next occurrence.
+		labelNames = append(labelNames, dedupe.Label(i.fingerprint(event)))
+	}
+	labelIDs, err := i.resolveLabelIDs(labelNames)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not resolve forgejo label ids")
+	}
+
+	update := issuePayload{Labels: labelIDs}
+	if existing.State == "closed" {
+		update.State = "open"
+	}
+	req, err := i.newRequest(http.MethodPatch, i.issueURL(existing.Index), update)
+	if err != nil {
+		return err
+	}
+	if _, err := i.do(req, http.StatusOK); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not update forgejo issue")
+	}
+
+	if i.options.Dedupe != nil && i.options.Dedupe.MaxCommentsPerIssue > 0 {
+		count, err := i.commentCount(existing.Index)
+		if err == nil && count >= i.options.Dedupe.MaxCommentsPerIssue {
+			return nil
+		}
+	}
+
+	comment := commentPayload{
+		Body: fmt.Sprintf("Matched again at %s", time.Now().UTC().Format(time.RFC3339)),
+	}
+	if extracted := event.ExtractedResults; len(extracted) > 0 {
+		comment.Body += fmt.Sprintf("\n\nNewly observed values:\n- %s", strings.Join(extracted, "\n- "))
+	}
+	req, err = i.newRequest(http.MethodPost, i.commentsURL(existing.Index), comment)
+	if err != nil {
+		return err
+	}
+	if _, err := i.do(req, http.StatusCreated); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not comment on forgejo issue")
+	}
+	return nil
+}
+
+// commentCount returns how many comments already exist on issue index.
+func (i *Integration) commentCount(index int64) (int, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, i.commentsURL(index), nil)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not build forgejo comments request")
+	}
+	i.authenticate(req)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not list forgejo comments")
+	}
+	defer resp.Body.Close()
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not read forgejo comments response")
+	}
+	var comments []struct{}
+	if err := json.Unmarshal(bin, &comments); err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not unmarshal forgejo comments response")
+	}
+	return len(comments), nil
+}
+
+// findByFingerprint searches open and closed issues for one carrying
+// fingerprint, either as a label or as a hidden comment in the body,
+// depending on the configured storage mode. Forgejo's issue search filters
+// labels by ID, so the label-storage path looks the fingerprint label's ID
+// up first; if it doesn't exist yet, no issue can carry it.
+func (i *Integration) findByFingerprint(fingerprint string) (*issueResponse, error) {
+	query := url.Values{}
+	query.Set("type", "issues")
+	query.Set("state", "all")
+	if i.options.Dedupe.UsesLabel() {
+		labelID, err := i.findLabelID(dedupe.Label(fingerprint))
+		if err != nil {
+			return nil, err
+		}
+		if labelID == 0 {
+			return nil, nil
+		}
+		query.Set("labels", strconv.FormatInt(labelID, 10))
+	} else {
+		query.Set("q", fingerprint)
+	}
+
+	searchURL := i.issuesURL() + "?" + query.Encode()
+	req, err := retryablehttp.NewRequest(http.MethodGet, searchURL, nil)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not build forgejo search request")
+	}
+	i.authenticate(req)
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not search forgejo issues")
+	}
+	defer resp.Body.Close()
+
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not read forgejo search response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not search forgejo issues got status code %v", resp.StatusCode)
+	}
+
+	var issues []issueResponse
+	if err := json.Unmarshal(bin, &issues); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not unmarshal forgejo search response")
+	}
+	marker := dedupe.Comment(fingerprint)
+	for idx := range issues {
+		if i.options.Dedupe.UsesLabel() || strings.Contains(issues[idx].Body, marker) {
+			return &issues[idx], nil
+		}
+	}
+	return nil, nil
+}
+
+// labelsForSeverity maps the event severity to the configured allow-list of
+// labels, falling back to the raw severity string.
+func (i *Integration) labelsForSeverity(event *output.ResultEvent) []string {
+	if !i.options.SeverityAsLabel {
+		return nil
+	}
+	severity := strings.ToLower(event.Info.SeverityHolder.Severity.String())
+	if label, ok := i.options.SeverityLabels[severity]; ok {
+		return []string{label}
+	}
+	return []string{severity}
+}
+
+// listLabels returns every label defined on the repository.
+func (i *Integration) listLabels() ([]forgejoLabel, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, i.labelsURL(), nil)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not build forgejo labels request")
+	}
+	i.authenticate(req)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not list forgejo labels")
+	}
+	defer resp.Body.Close()
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not read forgejo labels response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not list forgejo labels got status code %v", resp.StatusCode)
+	}
+	var labels []forgejoLabel
+	if err := json.Unmarshal(bin, &labels); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not unmarshal forgejo labels response")
+	}
+	return labels, nil
+}
+
+// findLabelID returns the ID of the repository label named name, or 0 if no
+// such label exists yet.
+func (i *Integration) findLabelID(name string) (int64, error) {
+	labels, err := i.listLabels()
+	if err != nil {
+		return 0, err
+	}
+	for _, label := range labels {
+		if label.Name == name {
+			return label.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// createLabel creates a new repository label named name and returns its ID.
+func (i *Integration) createLabel(name string) (int64, error) {
+	payload := struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	}{Name: name, Color: "#ededed"}
+
+	req, err := i.newRequest(http.MethodPost, i.labelsURL(), payload)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not create forgejo label")
+	}
+	defer resp.Body.Close()
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not read forgejo label response")
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("could not create forgejo label got status code %v: %s", resp.StatusCode, string(bin))
+	}
+	var label forgejoLabel
+	if err := json.Unmarshal(bin, &label); err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not unmarshal forgejo label response")
+	}
+	return label.ID, nil
+}
+
+// resolveLabelIDs maps label names to their repository label IDs, creating
+// any label that doesn't exist yet (e.g. a fingerprint label on its first
+// use). Forgejo's issue create/update payloads only accept label IDs.
+func (i *Integration) resolveLabelIDs(names []string) ([]int64, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	existing, err := i.listLabels()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]int64, len(existing))
+	for _, label := range existing {
+		byName[label.Name] = label.ID
+	}
+
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		if id, ok := byName[name]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		id, err := i.createLabel(name)
+		if err != nil {
+			return nil, err
+		}
+		byName[name] = id
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// resolveMilestoneID returns the ID of the repository milestone titled
+// title, creating it if it doesn't exist yet.
+func (i *Integration) resolveMilestoneID(title string) (int64, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, i.milestonesURL()+"?state=all", nil)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not build forgejo milestones request")
+	}
+	i.authenticate(req)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not list forgejo milestones")
+	}
+	defer resp.Body.Close()
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not read forgejo milestones response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("could not list forgejo milestones got status code %v", resp.StatusCode)
+	}
+	var milestones []forgejoMilestone
+	if err := json.Unmarshal(bin, &milestones); err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not unmarshal forgejo milestones response")
+	}
+	for _, milestone := range milestones {
+		if milestone.Title == title {
+			return milestone.ID, nil
+		}
+	}
+
+	payload := struct {
+		Title string `json:"title"`
+	}{Title: title}
+	createReq, err := i.newRequest(http.MethodPost, i.milestonesURL(), payload)
+	if err != nil {
+		return 0, err
+	}
+	createResp, err := i.client.Do(createReq)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not create forgejo milestone")
+	}
+	defer createResp.Body.Close()
+	createBin, err := io.ReadAll(createResp.Body)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not read forgejo milestone response")
+	}
+	if createResp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("could not create forgejo milestone got status code %v: %s", createResp.StatusCode, string(createBin))
+	}
+	var created forgejoMilestone
+	if err := json.Unmarshal(createBin, &created); err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not unmarshal forgejo milestone response")
+	}
+	return created.ID, nil
+}
+
+func (i *Integration) repoURL() string {
+	return fmt.Sprintf("%s/api/v1/repos/%s/%s", strings.TrimRight(i.baseURL.String(), "/"), i.options.Owner, i.options.Repository)
+}
+
+func (i *Integration) issuesURL() string {
+	return fmt.Sprintf("%s/issues", i.repoURL())
+}
+
+func (i *Integration) issueURL(index int64) string {
+	return fmt.Sprintf("%s/%d", i.issuesURL(), index)
+}
+
+func (i *Integration) commentsURL(index int64) string {
+	return fmt.Sprintf("%s/comments", i.issueURL(index))
+}
+
+func (i *Integration) labelsURL() string {
+	return fmt.Sprintf("%s/labels", i.repoURL())
+}
+
+func (i *Integration) milestonesURL() string {
+	return fmt.Sprintf("%s/milestones", i.repoURL())
+}
+
+func (i *Integration) newRequest(method, reqURL string, payload interface{}) (*retryablehttp.Request, error) {
+	bin, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not marshal forgejo request")
+	}
+	req, err := retryablehttp.NewRequest(method, reqURL, bytes.NewReader(bin))
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not build forgejo request")
+	}
+	i.authenticate(req)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// authenticate sets the bearer auth header Forgejo expects for PATs, as
+// opposed to Gitea's "token <token>" scheme.
+func (i *Integration) authenticate(req *retryablehttp.Request) {
+	req.Header.Set("Authorization", "Bearer "+i.options.Token)
+	req.Header.Set("Accept", "application/json")
+}
+
+func (i *Integration) do(req *retryablehttp.Request, wantStatus int) (*issueResponse, error) {
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not read forgejo response")
+	}
+	if resp.StatusCode != wantStatus {
+		return nil, fmt.Errorf("got status code %v, expected %v: %s", resp.StatusCode, wantStatus, string(bin))
+	}
+	if len(bin) == 0 {
+		return nil, nil
+	}
+	var issue issueResponse
+	if err := json.Unmarshal(bin, &issue); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not unmarshal forgejo response")
+	}
+	return &issue, nil
+}
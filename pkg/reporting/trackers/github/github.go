@@ -0,0 +1,312 @@
+// Package github implements a reporting.Client for GitHub issue trackers.
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/retryablehttp-go"
+	errorutil "github.com/projectdiscovery/utils/errors"
+	"github.com/scottdharvey/nuclei/v3/pkg/output"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/format"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/dedupe"
+)
+
+const apiBaseURL = "https://api.github.com"
+
+// Options contains the configuration options for the GitHub issue tracker.
+type Options struct {
+	// Token is the personal access token for the github repository
+	Token string `yaml:"token"`
+	// Owner is the owner of the repository
+	Owner string `yaml:"owner"`
+	// Repository is the name of the repository
+	Repository string `yaml:"repository"`
+	// IssueTemplate is the issue template for reporting
+	IssueTemplate string `yaml:"issue-template"`
+	// SeverityAsLabel sends the severity as the label of the created issue
+	SeverityAsLabel bool `yaml:"severity-as-label"`
+	// SeverityLabels is a mapping of severity to an allow-listed label name.
+	// When empty, the raw severity string is used as the label.
+	SeverityLabels map[string]string `yaml:"severity-labels"`
+	// Dedupe configures fingerprint based issue deduplication. When nil,
+	// FindOrUpdateIssue still deduplicates, fingerprinting all extracted
+	// values and storing the fingerprint as a hidden comment in the body.
+	Dedupe *dedupe.Options `yaml:"dedupe"`
+
+	HttpClient *retryablehttp.Client `yaml:"-"`
+}
+
+// Integration is a client for the GitHub issue tracker.
+type Integration struct {
+	options *Options
+	client  *retryablehttp.Client
+}
+
+// New creates a new issue tracker integration for GitHub.
+func New(options *Options) (*Integration, error) {
+	if options.Token == "" {
+		return nil, fmt.Errorf("github token cannot be empty")
+	}
+	client := options.HttpClient
+	if client == nil {
+		client = retryablehttp.NewClient(retryablehttp.DefaultOptionsSingle)
+	}
+	return &Integration{options: options, client: client}, nil
+}
+
+type issuePayload struct {
+	Title  string   `json:"title,omitempty"`
+	Body   string   `json:"body,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+	State  string   `json:"state,omitempty"`
+}
+
+type commentPayload struct {
+	Body string `json:"body"`
+}
+
+type issueResponse struct {
+	Number  int64    `json:"number"`
+	State   string   `json:"state"`
+	Body    string   `json:"body"`
+	HTMLURL string   `json:"html_url"`
+	Labels  []string `json:"-"`
+}
+
+type searchResponse struct {
+	Items []issueResponse `json:"items"`
+}
+
+// CreateIssue creates a new issue on the configured GitHub repository
+// unconditionally, without checking for an existing match. Most callers
+// should prefer FindOrUpdateIssue to avoid duplicate issues on repeated
+// scans.
+func (i *Integration) CreateIssue(event *output.ResultEvent) error {
+	_, err := i.createIssue(event, i.fingerprint(event))
+	return err
+}
+
+// FindOrUpdateIssue reconciles event against the tracker: if an issue
+// already exists for this finding's fingerprint, it is reopened if closed,
+// has a new occurrence comment appended, and has its severity labels
+// refreshed; otherwise a new issue is created with the fingerprint recorded
+// for future runs.
+func (i *Integration) FindOrUpdateIssue(event *output.ResultEvent) error {
+	fp := i.fingerprint(event)
+	existing, err := i.findByFingerprint(fp)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not search for existing github issue")
+	}
+	if existing == nil {
+		_, err := i.createIssue(event, fp)
+		return err
+	}
+	return i.updateIssue(existing, event)
+}
+
+func (i *Integration) fingerprint(event *output.ResultEvent) string {
+	var fields []string
+	if i.options.Dedupe != nil {
+		fields = i.options.Dedupe.FingerprintFields
+	}
+	return dedupe.Fingerprint(event, fields)
+}
+
+func (i *Integration) createIssue(event *output.ResultEvent, fingerprint string) (*issueResponse, error) {
+	body := format.MarkdownDescription(event)
+	labels := i.labelsForSeverity(event)
+	if i.options.Dedupe.UsesLabel() {
+		labels = append(labels, dedupe.Label(fingerprint))
+	} else {
+		body = body + "\n\n" + dedupe.Comment(fingerprint)
+	}
+
+	payload := issuePayload{Title: format.Summary(event), Body: body, Labels: labels}
+	req, err := i.newRequest(http.MethodPost, i.issuesURL(), payload)
+	if err != nil {
+		return nil, err
+	}
+	issue, err := i.do(req, http.StatusCreated)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create github issue")
+	}
+	return issue, nil
+}
+
+func (i *Integration) updateIssue(existing *issueResponse, event *output.ResultEvent) error {
+	labels := i.labelsForSeverity(event)
+	if i.options.Dedupe.UsesLabel() {
+		// the PATCH below replaces the issue's full label set, so the
+		// fingerprint label has to be re-sent on every update or it gets
+		// wiped, breaking findByFingerprint on the next occurrence.
+		labels = append(labels, dedupe.Label(i.fingerprint(event)))
+	}
+	update := issuePayload{Labels: labels}
+	if existing.State == "closed" {
+		update.State = "open"
+	}
+	req, err := i.newRequest(http.MethodPatch, i.issueURL(existing.Number), update)
+	if err != nil {
+		return err
+	}
+	if _, err := i.do(req, http.StatusOK); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not update github issue")
+	}
+
+	if i.options.Dedupe != nil && i.options.Dedupe.MaxCommentsPerIssue > 0 {
+		count, err := i.commentCount(existing.Number)
+		if err == nil && count >= i.options.Dedupe.MaxCommentsPerIssue {
+			return nil
+		}
+	}
+
+	comment := commentPayload{Body: fmt.Sprintf("Matched again at %s", time.Now().UTC().Format(time.RFC3339))}
+	if extracted := event.ExtractedResults; len(extracted) > 0 {
+		comment.Body += fmt.Sprintf("\n\nNewly observed values:\n- %s", strings.Join(extracted, "\n- "))
+	}
+	req, err = i.newRequest(http.MethodPost, i.commentsURL(existing.Number), comment)
+	if err != nil {
+		return err
+	}
+	if _, err := i.do(req, http.StatusCreated); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not comment on github issue")
+	}
+	return nil
+}
+
+func (i *Integration) commentCount(number int64) (int, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, i.commentsURL(number), nil)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not build github comments request")
+	}
+	i.authenticate(req)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not list github comments")
+	}
+	defer resp.Body.Close()
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not read github comments response")
+	}
+	var comments []struct{}
+	if err := json.Unmarshal(bin, &comments); err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not unmarshal github comments response")
+	}
+	return len(comments), nil
+}
+
+func (i *Integration) findByFingerprint(fingerprint string) (*issueResponse, error) {
+	q := fmt.Sprintf("repo:%s/%s is:issue %s", i.options.Owner, i.options.Repository, fingerprint)
+	if i.options.Dedupe.UsesLabel() {
+		q = fmt.Sprintf("repo:%s/%s is:issue label:%q", i.options.Owner, i.options.Repository, dedupe.Label(fingerprint))
+	}
+	query := url.Values{}
+	query.Set("q", q)
+
+	searchURL := apiBaseURL + "/search/issues?" + query.Encode()
+	req, err := retryablehttp.NewRequest(http.MethodGet, searchURL, nil)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not build github search request")
+	}
+	i.authenticate(req)
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not search github issues")
+	}
+	defer resp.Body.Close()
+
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not read github search response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not search github issues got status code %v", resp.StatusCode)
+	}
+
+	var results searchResponse
+	if err := json.Unmarshal(bin, &results); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not unmarshal github search response")
+	}
+	marker := dedupe.Comment(fingerprint)
+	for idx := range results.Items {
+		if i.options.Dedupe.UsesLabel() || strings.Contains(results.Items[idx].Body, marker) {
+			return &results.Items[idx], nil
+		}
+	}
+	return nil, nil
+}
+
+func (i *Integration) labelsForSeverity(event *output.ResultEvent) []string {
+	if !i.options.SeverityAsLabel {
+		return nil
+	}
+	severity := strings.ToLower(event.Info.SeverityHolder.Severity.String())
+	if label, ok := i.options.SeverityLabels[severity]; ok {
+		return []string{label}
+	}
+	return []string{severity}
+}
+
+func (i *Integration) issuesURL() string {
+	return fmt.Sprintf("%s/repos/%s/%s/issues", apiBaseURL, i.options.Owner, i.options.Repository)
+}
+
+func (i *Integration) issueURL(number int64) string {
+	return fmt.Sprintf("%s/%d", i.issuesURL(), number)
+}
+
+func (i *Integration) commentsURL(number int64) string {
+	return fmt.Sprintf("%s/comments", i.issueURL(number))
+}
+
+func (i *Integration) newRequest(method, reqURL string, payload interface{}) (*retryablehttp.Request, error) {
+	bin, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not marshal github request")
+	}
+	req, err := retryablehttp.NewRequest(method, reqURL, bytes.NewReader(bin))
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not build github request")
+	}
+	i.authenticate(req)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func (i *Integration) authenticate(req *retryablehttp.Request) {
+	req.Header.Set("Authorization", "Bearer "+i.options.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+}
+
+func (i *Integration) do(req *retryablehttp.Request, wantStatus int) (*issueResponse, error) {
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not read github response")
+	}
+	if resp.StatusCode != wantStatus {
+		return nil, fmt.Errorf("got status code %v, expected %v: %s", resp.StatusCode, wantStatus, string(bin))
+	}
+	if len(bin) == 0 {
+		return nil, nil
+	}
+	var issue issueResponse
+	if err := json.Unmarshal(bin, &issue); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not unmarshal github response")
+	}
+	return &issue, nil
+}
@@ -0,0 +1,321 @@
+// Package gitlab implements a reporting.Client for GitLab issue trackers.
+package gitlab
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/retryablehttp-go"
+	errorutil "github.com/projectdiscovery/utils/errors"
+	"github.com/scottdharvey/nuclei/v3/pkg/output"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/format"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/dedupe"
+)
+
+// Options contains the configuration options for the GitLab issue tracker.
+type Options struct {
+	// BaseURL is the base url of the gitlab instance, defaults to gitlab.com
+	BaseURL string `yaml:"base-url"`
+	// Token is the personal/project access token for the gitlab instance
+	Token string `yaml:"token"`
+	// ProjectName is the id or URL-encoded path of the project (e.g. "group%2Fproject")
+	ProjectName string `yaml:"project-name"`
+	// IssueLabel is a label applied to every created issue
+	IssueLabel string `yaml:"issue-label"`
+	// SeverityAsLabel sends the severity as the label of the created issue
+	SeverityAsLabel bool `yaml:"severity-as-label"`
+	// SeverityLabels is a mapping of severity to an allow-listed label name.
+	// When empty, the raw severity string is used as the label.
+	SeverityLabels map[string]string `yaml:"severity-labels"`
+	// Dedupe configures fingerprint based issue deduplication. When nil,
+	// FindOrUpdateIssue still deduplicates, fingerprinting all extracted
+	// values and storing the fingerprint as a hidden comment in the description.
+	Dedupe *dedupe.Options `yaml:"dedupe"`
+
+	HttpClient *retryablehttp.Client `yaml:"-"`
+}
+
+// Integration is a client for the GitLab issue tracker.
+type Integration struct {
+	options *Options
+	client  *retryablehttp.Client
+	baseURL *url.URL
+}
+
+// New creates a new issue tracker integration for GitLab.
+func New(options *Options) (*Integration, error) {
+	base := options.BaseURL
+	if base == "" {
+		base = "https://gitlab.com"
+	}
+	parsed, err := url.Parse(base)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not parse gitlab base-url")
+	}
+	client := options.HttpClient
+	if client == nil {
+		client = retryablehttp.NewClient(retryablehttp.DefaultOptionsSingle)
+	}
+	return &Integration{options: options, client: client, baseURL: parsed}, nil
+}
+
+type issuePayload struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Labels      string `json:"labels,omitempty"`
+	StateEvent  string `json:"state_event,omitempty"`
+}
+
+type commentPayload struct {
+	Body string `json:"body"`
+}
+
+type issueResponse struct {
+	IID         int64    `json:"iid"`
+	State       string   `json:"state"`
+	Description string   `json:"description"`
+	Labels      []string `json:"labels,omitempty"`
+	WebURL      string   `json:"web_url"`
+}
+
+// CreateIssue creates a new issue on the configured GitLab project
+// unconditionally, without checking for an existing match. Most callers
+// should prefer FindOrUpdateIssue to avoid duplicate issues on repeated
+// scans.
+func (i *Integration) CreateIssue(event *output.ResultEvent) error {
+	_, err := i.createIssue(event, i.fingerprint(event))
+	return err
+}
+
+// FindOrUpdateIssue reconciles event against the tracker: if an issue
+// already exists for this finding's fingerprint, it is reopened if closed,
+// has a new occurrence comment appended, and has its severity labels
+// refreshed; otherwise a new issue is created with the fingerprint recorded
+// for future runs.
+func (i *Integration) FindOrUpdateIssue(event *output.ResultEvent) error {
+	fp := i.fingerprint(event)
+	existing, err := i.findByFingerprint(fp)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not search for existing gitlab issue")
+	}
+	if existing == nil {
+		_, err := i.createIssue(event, fp)
+		return err
+	}
+	return i.updateIssue(existing, event)
+}
+
+func (i *Integration) fingerprint(event *output.ResultEvent) string {
+	var fields []string
+	if i.options.Dedupe != nil {
+		fields = i.options.Dedupe.FingerprintFields
+	}
+	return dedupe.Fingerprint(event, fields)
+}
+
+func (i *Integration) createIssue(event *output.ResultEvent, fingerprint string) (*issueResponse, error) {
+	description := format.MarkdownDescription(event)
+	labels := i.labelsForSeverity(event)
+	if i.options.IssueLabel != "" {
+		labels = append(labels, i.options.IssueLabel)
+	}
+	if i.options.Dedupe.UsesLabel() {
+		labels = append(labels, dedupe.Label(fingerprint))
+	} else {
+		description = description + "\n\n" + dedupe.Comment(fingerprint)
+	}
+
+	payload := issuePayload{Title: format.Summary(event), Description: description, Labels: strings.Join(labels, ",")}
+	req, err := i.newRequest(http.MethodPost, i.issuesURL(), payload)
+	if err != nil {
+		return nil, err
+	}
+	issue, err := i.do(req, http.StatusCreated)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create gitlab issue")
+	}
+	return issue, nil
+}
+
+func (i *Integration) updateIssue(existing *issueResponse, event *output.ResultEvent) error {
+	labels := i.labelsForSeverity(event)
+	if i.options.IssueLabel != "" {
+		labels = append(labels, i.options.IssueLabel)
+	}
+	if i.options.Dedupe.UsesLabel() {
+		// the PUT below replaces the issue's full label set, so the static
+		// IssueLabel and the fingerprint label both have to be re-sent on
+		// every update or they get wiped, breaking findByFingerprint on
+		// the next occurrence.
+		labels = append(labels, dedupe.Label(i.fingerprint(event)))
+	}
+	update := issuePayload{Labels: strings.Join(labels, ",")}
+	if existing.State == "closed" {
+		update.StateEvent = "reopen"
+	}
+	req, err := i.newRequest(http.MethodPut, i.issueURL(existing.IID), update)
+	if err != nil {
+		return err
+	}
+	if _, err := i.do(req, http.StatusOK); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not update gitlab issue")
+	}
+
+	if i.options.Dedupe != nil && i.options.Dedupe.MaxCommentsPerIssue > 0 {
+		count, err := i.commentCount(existing.IID)
+		if err == nil && count >= i.options.Dedupe.MaxCommentsPerIssue {
+			return nil
+		}
+	}
+
+	comment := commentPayload{Body: fmt.Sprintf("Matched again at %s", time.Now().UTC().Format(time.RFC3339))}
+	if extracted := event.ExtractedResults; len(extracted) > 0 {
+		comment.Body += fmt.Sprintf("\n\nNewly observed values:\n- %s", strings.Join(extracted, "\n- "))
+	}
+	req, err = i.newRequest(http.MethodPost, i.notesURL(existing.IID), comment)
+	if err != nil {
+		return err
+	}
+	if _, err := i.do(req, http.StatusCreated); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not comment on gitlab issue")
+	}
+	return nil
+}
+
+func (i *Integration) commentCount(iid int64) (int, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, i.notesURL(iid), nil)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not build gitlab notes request")
+	}
+	i.authenticate(req)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not list gitlab notes")
+	}
+	defer resp.Body.Close()
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not read gitlab notes response")
+	}
+	var notes []struct{}
+	if err := json.Unmarshal(bin, &notes); err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not unmarshal gitlab notes response")
+	}
+	return len(notes), nil
+}
+
+func (i *Integration) findByFingerprint(fingerprint string) (*issueResponse, error) {
+	query := url.Values{}
+	query.Set("scope", "all")
+	if i.options.Dedupe.UsesLabel() {
+		query.Set("labels", dedupe.Label(fingerprint))
+	} else {
+		query.Set("search", fingerprint)
+		query.Set("in", "description")
+	}
+
+	searchURL := i.issuesURL() + "?" + query.Encode()
+	req, err := retryablehttp.NewRequest(http.MethodGet, searchURL, nil)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not build gitlab search request")
+	}
+	i.authenticate(req)
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not search gitlab issues")
+	}
+	defer resp.Body.Close()
+
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not read gitlab search response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not search gitlab issues got status code %v", resp.StatusCode)
+	}
+
+	var issues []issueResponse
+	if err := json.Unmarshal(bin, &issues); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not unmarshal gitlab search response")
+	}
+	marker := dedupe.Comment(fingerprint)
+	for idx := range issues {
+		if i.options.Dedupe.UsesLabel() || strings.Contains(issues[idx].Description, marker) {
+			return &issues[idx], nil
+		}
+	}
+	return nil, nil
+}
+
+func (i *Integration) labelsForSeverity(event *output.ResultEvent) []string {
+	if !i.options.SeverityAsLabel {
+		return nil
+	}
+	severity := strings.ToLower(event.Info.SeverityHolder.Severity.String())
+	if label, ok := i.options.SeverityLabels[severity]; ok {
+		return []string{label}
+	}
+	return []string{severity}
+}
+
+func (i *Integration) issuesURL() string {
+	return fmt.Sprintf("%s/api/v4/projects/%s/issues", strings.TrimRight(i.baseURL.String(), "/"), url.PathEscape(i.options.ProjectName))
+}
+
+func (i *Integration) issueURL(iid int64) string {
+	return fmt.Sprintf("%s/%d", i.issuesURL(), iid)
+}
+
+func (i *Integration) notesURL(iid int64) string {
+	return fmt.Sprintf("%s/notes", i.issueURL(iid))
+}
+
+func (i *Integration) newRequest(method, reqURL string, payload interface{}) (*retryablehttp.Request, error) {
+	bin, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not marshal gitlab request")
+	}
+	req, err := retryablehttp.NewRequest(method, reqURL, bytes.NewReader(bin))
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not build gitlab request")
+	}
+	i.authenticate(req)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func (i *Integration) authenticate(req *retryablehttp.Request) {
+	req.Header.Set("PRIVATE-TOKEN", i.options.Token)
+	req.Header.Set("Accept", "application/json")
+}
+
+func (i *Integration) do(req *retryablehttp.Request, wantStatus int) (*issueResponse, error) {
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not read gitlab response")
+	}
+	if resp.StatusCode != wantStatus {
+		return nil, fmt.Errorf("got status code %v, expected %v: %s", resp.StatusCode, wantStatus, string(bin))
+	}
+	if len(bin) == 0 {
+		return nil, nil
+	}
+	var issue issueResponse
+	if err := json.Unmarshal(bin, &issue); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not unmarshal gitlab response")
+	}
+	return &issue, nil
+}
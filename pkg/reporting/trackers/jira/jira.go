@@ -0,0 +1,373 @@
+// Package jira implements a reporting.Client for Jira issue trackers.
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/retryablehttp-go"
+	errorutil "github.com/projectdiscovery/utils/errors"
+	"github.com/scottdharvey/nuclei/v3/pkg/output"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/format"
+	"github.com/scottdharvey/nuclei/v3/pkg/reporting/trackers/dedupe"
+)
+
+// Options contains the configuration options for the Jira issue tracker.
+type Options struct {
+	// CloudURL is the base url of the jira cloud/server instance
+	CloudURL string `yaml:"cloud-url"`
+	// Email is the email of the jira user
+	Email string `yaml:"email"`
+	// Token is the API token for the jira instance
+	Token string `yaml:"token"`
+	// ProjectName is the key of the jira project to create issues in
+	ProjectName string `yaml:"project-name"`
+	// IssueType is the type of issue to create, e.g. "Bug", "Task"
+	IssueType string `yaml:"issue-type"`
+	// SeverityAsLabel sends the severity as the label of the created issue
+	SeverityAsLabel bool `yaml:"severity-as-label"`
+	// SeverityLabels is a mapping of severity to an allow-listed label name.
+	// When empty, the raw severity string is used as the label.
+	SeverityLabels map[string]string `yaml:"severity-labels"`
+	// Dedupe configures fingerprint based issue deduplication. When nil,
+	// FindOrUpdateIssue still deduplicates, fingerprinting all extracted
+	// values and storing the fingerprint as a hidden comment in the description.
+	Dedupe *dedupe.Options `yaml:"dedupe"`
+
+	HttpClient *retryablehttp.Client `yaml:"-"`
+}
+
+// Integration is a client for the Jira issue tracker.
+type Integration struct {
+	options *Options
+	client  *retryablehttp.Client
+}
+
+// New creates a new issue tracker integration for Jira.
+func New(options *Options) (*Integration, error) {
+	if options.CloudURL == "" {
+		return nil, fmt.Errorf("jira cloud-url cannot be empty")
+	}
+	client := options.HttpClient
+	if client == nil {
+		client = retryablehttp.NewClient(retryablehttp.DefaultOptionsSingle)
+	}
+	return &Integration{options: options, client: client}, nil
+}
+
+type issueFields struct {
+	Project     map[string]string `json:"project"`
+	Summary     string            `json:"summary,omitempty"`
+	Description string            `json:"description,omitempty"`
+	IssueType   map[string]string `json:"issuetype,omitempty"`
+	Labels      []string          `json:"labels,omitempty"`
+}
+
+type issuePayload struct {
+	Fields issueFields `json:"fields"`
+}
+
+type updatePayload struct {
+	Fields issueFields `json:"fields"`
+}
+
+type commentPayload struct {
+	Body string `json:"body"`
+}
+
+type issueResponse struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Description string `json:"description"`
+		Status      struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		Labels []string `json:"labels"`
+	} `json:"fields"`
+}
+
+type searchResponse struct {
+	Issues []issueResponse `json:"issues"`
+}
+
+type transitionsResponse struct {
+	Transitions []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"transitions"`
+}
+
+// CreateIssue creates a new issue on the configured Jira project
+// unconditionally, without checking for an existing match. Most callers
+// should prefer FindOrUpdateIssue to avoid duplicate issues on repeated
+// scans.
+func (i *Integration) CreateIssue(event *output.ResultEvent) error {
+	_, err := i.createIssue(event, i.fingerprint(event))
+	return err
+}
+
+// FindOrUpdateIssue reconciles event against the tracker: if an issue
+// already exists for this finding's fingerprint, it is reopened if
+// resolved/closed, has a new occurrence comment appended, and has its
+// severity labels refreshed; otherwise a new issue is created with the
+// fingerprint recorded for future runs.
+func (i *Integration) FindOrUpdateIssue(event *output.ResultEvent) error {
+	fp := i.fingerprint(event)
+	existing, err := i.findByFingerprint(fp)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not search for existing jira issue")
+	}
+	if existing == nil {
+		_, err := i.createIssue(event, fp)
+		return err
+	}
+	return i.updateIssue(existing, event)
+}
+
+func (i *Integration) fingerprint(event *output.ResultEvent) string {
+	var fields []string
+	if i.options.Dedupe != nil {
+		fields = i.options.Dedupe.FingerprintFields
+	}
+	return dedupe.Fingerprint(event, fields)
+}
+
+func (i *Integration) createIssue(event *output.ResultEvent, fingerprint string) (*issueResponse, error) {
+	description := format.MarkdownDescription(event)
+	labels := i.labelsForSeverity(event)
+	if i.options.Dedupe.UsesLabel() {
+		labels = append(labels, dedupe.Label(fingerprint))
+	} else {
+		description = description + "\n\n" + dedupe.Comment(fingerprint)
+	}
+
+	payload := issuePayload{Fields: issueFields{
+		Project:     map[string]string{"key": i.options.ProjectName},
+		Summary:     format.Summary(event),
+		Description: description,
+		IssueType:   map[string]string{"name": i.options.IssueType},
+		Labels:      labels,
+	}}
+
+	req, err := i.newRequest(http.MethodPost, i.issueURL(""), payload)
+	if err != nil {
+		return nil, err
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := i.do(req, http.StatusCreated, &created); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not create jira issue")
+	}
+	return &issueResponse{Key: created.Key}, nil
+}
+
+func (i *Integration) updateIssue(existing *issueResponse, event *output.ResultEvent) error {
+	labels := i.labelsForSeverity(event)
+	if i.options.Dedupe.UsesLabel() {
+		// the PUT below replaces the issue's full label set, so the
+		// fingerprint label has to be re-sent on every update or it gets
+		// wiped, breaking findByFingerprint on the next occurrence.
+		labels = append(labels, dedupe.Label(i.fingerprint(event)))
+	}
+	update := updatePayload{Fields: issueFields{Labels: labels}}
+	req, err := i.newRequest(http.MethodPut, i.issueURL(existing.Key), update)
+	if err != nil {
+		return err
+	}
+	if err := i.do(req, http.StatusNoContent, nil); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not update jira issue")
+	}
+
+	if isClosed(existing.Fields.Status.Name) {
+		if err := i.reopen(existing.Key); err != nil {
+			return errorutil.NewWithErr(err).Msgf("could not reopen jira issue")
+		}
+	}
+
+	if i.options.Dedupe != nil && i.options.Dedupe.MaxCommentsPerIssue > 0 {
+		count, err := i.commentCount(existing.Key)
+		if err == nil && count >= i.options.Dedupe.MaxCommentsPerIssue {
+			return nil
+		}
+	}
+
+	comment := commentPayload{Body: fmt.Sprintf("Matched again at %s", time.Now().UTC().Format(time.RFC3339))}
+	if extracted := event.ExtractedResults; len(extracted) > 0 {
+		comment.Body += fmt.Sprintf("\n\nNewly observed values:\n- %s", strings.Join(extracted, "\n- "))
+	}
+	req, err = i.newRequest(http.MethodPost, i.commentsURL(existing.Key), comment)
+	if err != nil {
+		return err
+	}
+	if err := i.do(req, http.StatusCreated, nil); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not comment on jira issue")
+	}
+	return nil
+}
+
+// reopen transitions a resolved/closed issue back to an open state. Jira
+// requires a two-step dance: list the transitions available from the
+// issue's current status, then POST the id of whichever looks like a
+// reopen/in-progress transition.
+func (i *Integration) reopen(key string) error {
+	req, err := retryablehttp.NewRequest(http.MethodGet, i.transitionsURL(key), nil)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not build jira transitions request")
+	}
+	i.authenticate(req)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not list jira transitions")
+	}
+	defer resp.Body.Close()
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not read jira transitions response")
+	}
+	var transitions transitionsResponse
+	if err := json.Unmarshal(bin, &transitions); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not unmarshal jira transitions response")
+	}
+	var transitionID string
+	for _, t := range transitions.Transitions {
+		lower := strings.ToLower(t.Name)
+		if strings.Contains(lower, "reopen") || strings.Contains(lower, "open") || strings.Contains(lower, "progress") {
+			transitionID = t.ID
+			break
+		}
+	}
+	if transitionID == "" {
+		return nil
+	}
+	payload := map[string]interface{}{"transition": map[string]string{"id": transitionID}}
+	req, err = i.newRequest(http.MethodPost, i.transitionsURL(key), payload)
+	if err != nil {
+		return err
+	}
+	return i.do(req, http.StatusNoContent, nil)
+}
+
+func isClosed(status string) bool {
+	lower := strings.ToLower(status)
+	return lower == "done" || lower == "closed" || lower == "resolved"
+}
+
+func (i *Integration) commentCount(key string) (int, error) {
+	req, err := retryablehttp.NewRequest(http.MethodGet, i.commentsURL(key), nil)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not build jira comments request")
+	}
+	i.authenticate(req)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not list jira comments")
+	}
+	defer resp.Body.Close()
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not read jira comments response")
+	}
+	var result struct {
+		Comments []struct{} `json:"comments"`
+	}
+	if err := json.Unmarshal(bin, &result); err != nil {
+		return 0, errorutil.NewWithErr(err).Msgf("could not unmarshal jira comments response")
+	}
+	return len(result.Comments), nil
+}
+
+func (i *Integration) findByFingerprint(fingerprint string) (*issueResponse, error) {
+	jql := fmt.Sprintf(`project = %q AND text ~ %q`, i.options.ProjectName, fingerprint)
+	if i.options.Dedupe.UsesLabel() {
+		jql = fmt.Sprintf(`project = %q AND labels = %q`, i.options.ProjectName, dedupe.Label(fingerprint))
+	}
+	payload := map[string]interface{}{"jql": jql, "maxResults": 1}
+
+	req, err := i.newRequest(http.MethodPost, i.options.CloudURL+"/rest/api/2/search", payload)
+	if err != nil {
+		return nil, err
+	}
+	var results searchResponse
+	if err := i.do(req, http.StatusOK, &results); err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not search jira issues")
+	}
+	if len(results.Issues) == 0 {
+		return nil, nil
+	}
+	return &results.Issues[0], nil
+}
+
+func (i *Integration) labelsForSeverity(event *output.ResultEvent) []string {
+	if !i.options.SeverityAsLabel {
+		return nil
+	}
+	severity := strings.ToLower(event.Info.SeverityHolder.Severity.String())
+	if label, ok := i.options.SeverityLabels[severity]; ok {
+		return []string{label}
+	}
+	return []string{severity}
+}
+
+func (i *Integration) issueURL(key string) string {
+	base := strings.TrimRight(i.options.CloudURL, "/") + "/rest/api/2/issue"
+	if key == "" {
+		return base
+	}
+	return base + "/" + key
+}
+
+func (i *Integration) commentsURL(key string) string {
+	return fmt.Sprintf("%s/comment", i.issueURL(key))
+}
+
+func (i *Integration) transitionsURL(key string) string {
+	return fmt.Sprintf("%s/transitions", i.issueURL(key))
+}
+
+func (i *Integration) newRequest(method, reqURL string, payload interface{}) (*retryablehttp.Request, error) {
+	bin, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not marshal jira request")
+	}
+	req, err := retryablehttp.NewRequest(method, reqURL, bytes.NewReader(bin))
+	if err != nil {
+		return nil, errorutil.NewWithErr(err).Msgf("could not build jira request")
+	}
+	i.authenticate(req)
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func (i *Integration) authenticate(req *retryablehttp.Request) {
+	req.SetBasicAuth(i.options.Email, i.options.Token)
+	req.Header.Set("Accept", "application/json")
+}
+
+func (i *Integration) do(req *retryablehttp.Request, wantStatus int, out interface{}) error {
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not read jira response")
+	}
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("got status code %v, expected %v: %s", resp.StatusCode, wantStatus, string(bin))
+	}
+	if out == nil || len(bin) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(bin, out); err != nil {
+		return errorutil.NewWithErr(err).Msgf("could not unmarshal jira response")
+	}
+	return nil
+}